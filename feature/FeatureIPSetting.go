@@ -4,6 +4,7 @@ import (
 	syslog "../syslog"
 	xenstoreclient "../xenstoreclient"
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -13,8 +14,16 @@ import (
 	"time"
 )
 
+// FeatureIPSettingClient is the contract the xe-daemon main loop drives:
+// it must construct one with NewFeatureIPSetting, passing a context it
+// controls, call Run once, and call Stop on shutdown so the watch loop
+// started by Run gets torn down instead of leaking. The daemon's call
+// site lives outside the feature package (and outside this checkout) -
+// it must be updated alongside this interface, passing a real,
+// cancellable context and invoking Stop when the daemon exits.
 type FeatureIPSettingClient interface {
 	Run() error
+	Stop()
 }
 
 type FeatureIPSetting struct {
@@ -22,6 +31,8 @@ type FeatureIPSetting struct {
 	Enabled bool
 	Debug   bool
 	logger  *log.Logger
+	ctx     context.Context
+	cancel  context.CancelFunc
 }
 
 const (
@@ -37,13 +48,26 @@ const (
 	gatewaySubKey     = "/static-ip-setting/gateway"
 	address6SubKey    = "/static-ip-setting/address6"
 	gateway6SubKey    = "/static-ip-setting/gateway6"
+	dnsSubKey         = "/static-ip-setting/dns"
+	dns6SubKey        = "/static-ip-setting/dns6"
+	// routesSubKey holds a ";"-separated list of "cidr,via" entries, per
+	// the documented schema, e.g.
+	// "10.0.1.0/24,10.0.0.1;2001:db8:1::/64,2001:db8::1". See
+	// parseRoutes for the parser.
+	routesSubKey = "/static-ip-setting/routes"
 )
 
 const (
 	LoggerName string = "FeatureIPSetting"
 )
 
-func NewFeatureIPSetting(Client xenstoreclient.XenStoreClient, Enabled bool, Debug bool) (FeatureIPSettingClient, error) {
+// advertiseVersion is written to advertiseKey so dom0 can tell which
+// version of the static-ip-setting xenstore schema this guest agent
+// understands. Bump it whenever the schema gains new sub-keys that
+// older agents would silently ignore.
+const advertiseVersion = "2"
+
+func NewFeatureIPSetting(ctx context.Context, Client xenstoreclient.XenStoreClient, Enabled bool, Debug bool) (FeatureIPSettingClient, error) {
 	var loggerWriter io.Writer = os.Stderr
 	var topic string = LoggerName
 	if w, err := syslog.NewSyslogWriter(topic); err == nil {
@@ -55,17 +79,20 @@ func NewFeatureIPSetting(Client xenstoreclient.XenStoreClient, Enabled bool, Deb
 	}
 	logger := log.New(loggerWriter, topic, 0)
 
+	runCtx, cancel := context.WithCancel(ctx)
 	return &FeatureIPSetting{
 		Client:  Client,
 		Enabled: Enabled,
 		Debug:   Debug,
 		logger:  logger,
+		ctx:     runCtx,
+		cancel:  cancel,
 	}, nil
 }
 
 func (f *FeatureIPSetting) Enable() {
 	if f.Enabled {
-		f.Client.Write(advertiseKey, "1")
+		f.Client.Write(advertiseKey, advertiseVersion)
 	} else {
 		f.Client.Write(advertiseKey, "0")
 	}
@@ -91,70 +118,327 @@ func (f *FeatureIPSetting) GetChildrens(key string) []string {
 type OSType int
 
 const (
-	OTHER  OSType = 0
-	CENTOS OSType = 1
+	OTHER OSType = iota
+	CENTOS
+	RHEL
+	FEDORA
+	DEBIAN
+	UBUNTU
+	SUSE
 )
 
+// GetCurrentOSType detects the guest's distro. It first consults the
+// legacy /var/cache/xe-linux-distribution file written by
+// xe-linux-distribution, then falls back to the standard
+// /etc/os-release so the detector keeps working on distros that never
+// shipped that XenServer-specific script.
 func GetCurrentOSType() OSType {
+	if osType, ok := osTypeFromXeLinuxDistribution(); ok {
+		return osType
+	}
+	if osType, ok := osTypeFromOSRelease(); ok {
+		return osType
+	}
+	return OTHER
+}
+
+func osTypeFromXeLinuxDistribution() (OSType, bool) {
 	distributionFile, err := os.OpenFile("/var/cache/xe-linux-distribution", os.O_RDONLY, 0666)
 	if err != nil {
-		return OTHER
+		return OTHER, false
 	}
 	defer distributionFile.Close()
-	scanner := bufio.NewScanner(distributionFile)
+
+	values := parseKeyValueFile(distributionFile)
+	if values["os_distro"] == "centos" {
+		return CENTOS, true
+	}
+	return OTHER, false
+}
+
+func osTypeFromOSRelease() (OSType, bool) {
+	osReleaseFile, err := os.OpenFile("/etc/os-release", os.O_RDONLY, 0666)
+	if err != nil {
+		return OTHER, false
+	}
+	defer osReleaseFile.Close()
+
+	return classifyOSRelease(parseKeyValueFile(osReleaseFile))
+}
+
+// classifyOSRelease maps the ID/ID_LIKE fields parsed from /etc/os-release
+// to the OSType SelectNetworkBackend dispatches on.
+func classifyOSRelease(values map[string]string) (OSType, bool) {
+	ids := values["ID"] + " " + values["ID_LIKE"]
+	switch {
+	case strings.Contains(ids, "centos"):
+		return CENTOS, true
+	case strings.Contains(ids, "fedora"):
+		return FEDORA, true
+	case strings.Contains(ids, "rhel"):
+		return RHEL, true
+	case strings.Contains(ids, "ubuntu"):
+		return UBUNTU, true
+	case strings.Contains(ids, "debian"):
+		return DEBIAN, true
+	case strings.Contains(ids, "suse"):
+		return SUSE, true
+	}
+	return OTHER, false
+}
+
+// parseKeyValueFile reads KEY=value / KEY="value" lines, as found in
+// both /var/cache/xe-linux-distribution and /etc/os-release.
+func parseKeyValueFile(r io.Reader) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
-		if strings.Contains(line, "=") {
-			parts := strings.SplitN(line, "=", 2)
-			k := strings.TrimSpace(parts[0])
-			v := strings.TrimSpace(strings.Trim(strings.TrimSpace(parts[1]), "\""))
-			if k == "os_distro" && v == "centos" {
-				return CENTOS
-			}
+		if !strings.Contains(line, "=") {
+			continue
 		}
+		parts := strings.SplitN(line, "=", 2)
+		k := strings.TrimSpace(parts[0])
+		v := strings.TrimSpace(strings.Trim(strings.TrimSpace(parts[1]), "\""))
+		values[k] = v
 	}
-	return OTHER
+	return values
 }
 
-func (f *FeatureIPSetting) ConfigStaticIP(vifKey string, mac string, isIPv6 bool, osType OSType) error {
+// parseDNSList parses a comma-separated list of DNS server addresses
+// as found under the dns/dns6 static-ip-setting sub-keys.
+func parseDNSList(raw string) []net.IP {
+	var servers []net.IP
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if ip := net.ParseIP(field); ip != nil {
+			servers = append(servers, ip)
+		}
+	}
+	return servers
+}
+
+// parseRoutes parses the routes sub-key (see routesSubKey): a
+// ";"-separated list of "cidr,via" entries, matching the request's
+// documented schema for a single entry. ";" separates entries rather
+// than "," so that the comma inside "cidr,via" stays unambiguous.
+func parseRoutes(raw string) ([]Route, error) {
+	var routes []Route
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ",")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed route entry %q, want \"cidr,via\"", entry)
+		}
+		_, destination, err := net.ParseCIDR(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("parse route destination %q: %s", fields[0], err.Error())
+		}
+		via := net.ParseIP(strings.TrimSpace(fields[1]))
+		if via == nil {
+			return nil, fmt.Errorf("parse route via %q", fields[1])
+		}
+		routes = append(routes, Route{Destination: destination, Via: via})
+	}
+	return routes, nil
+}
+
+// readFamilyConfig reads the address/gateway/dns xenstore sub-keys for a
+// single address family (v4 or v6) under vifKey.
+func (f *FeatureIPSetting) readFamilyConfig(vifKey string, isIPv6 bool) (*IPConfig, []net.IP, *IPSettingError) {
 	addressKey := vifKey + addressSubKey
 	gatewatKey := vifKey + gatewaySubKey
+	dnsKey := vifKey + dnsSubKey
 	if isIPv6 {
 		addressKey = vifKey + address6SubKey
 		gatewatKey = vifKey + gateway6SubKey
+		dnsKey = vifKey + dns6SubKey
 	}
 
+	var ipConfig *IPConfig
 	if address, err := f.Client.Read(addressKey); err == nil {
 		if ip, ipNet, err := net.ParseCIDR(address); err == nil {
-			switch osType {
-			case CENTOS:
-				f.logger.Printf("FeatureIPSetting Set IP %s MASK %s on Centos\n", ip.String(), ipNet.String())
-			default:
-				f.logger.Printf("FeatureIPSetting Set IP %s MASK %s on Other OS\n", ip.String(), ipNet.String())
-			}
+			ipNet.IP = ip
+			ipConfig = &IPConfig{Address: ipNet}
 		} else {
 			f.logger.Printf("FeatureIPSetting ParseCIDR [%s] failed with %s\n", address, err.Error())
+			return nil, nil, newIPSettingError(ErrParseFailure, "parse address %q: %s", address, err.Error())
 		}
 	} else {
 		f.logger.Printf("FeatureIPSetting Set IP failed with %s\n", err.Error())
+		return nil, nil, newIPSettingError(ErrParseFailure, "read address: %s", err.Error())
 	}
 
 	if gateway, err := f.Client.Read(gatewatKey); err == nil {
 		if gatewayAddress := net.ParseIP(gateway); gatewayAddress != nil {
-			switch osType {
-			case CENTOS:
-				f.logger.Printf("FeatureIPSetting Set gateway with %s on Centos\n", gatewayAddress.String())
-			default:
-				f.logger.Printf("FeatureIPSetting Set gateway with %s on other OS\n", gatewayAddress.String())
-			}
-
+			ipConfig.Gateway = gatewayAddress
 		} else {
 			f.logger.Printf("FeatureIPSetting Invalid gateway %s\n", gateway)
+			return nil, nil, newIPSettingError(ErrParseFailure, "parse gateway %q", gateway)
 		}
 	} else {
 		f.logger.Printf("FeatureIPSetting Set gateway failed with %s\n", err.Error())
 	}
-	return nil
+
+	var dns []net.IP
+	if raw, err := f.Client.Read(dnsKey); err == nil {
+		dns = parseDNSList(raw)
+	}
+
+	return ipConfig, dns, nil
+}
+
+// ConfigStaticIP applies the static IP configuration for whichever of v4
+// and v6 is enabled on the VIF at vifKey, in a single pass: both
+// families are read and handed to the NetworkBackend together so one
+// backend.Apply call produces the whole interface configuration, rather
+// than two independent passes that would each overwrite the other's
+// whole-interface config file.
+func (f *FeatureIPSetting) ConfigStaticIP(vifKey string, mac string, enableV4 bool, enableV6 bool, osType OSType) *IPSettingError {
+	var v4, v6 *IPConfig
+	var dns []net.IP
+	var v4Err, v6Err *IPSettingError
+
+	if enableV4 {
+		cfg, d, err := f.readFamilyConfig(vifKey, false)
+		v4, dns, v4Err = cfg, d, err
+	}
+	if enableV6 {
+		cfg, d, err := f.readFamilyConfig(vifKey, true)
+		v6, v6Err = cfg, err
+		dns = append(dns, d...)
+	}
+	parseErr := combineIPSettingErrors(v4Err, v6Err)
+	if v4 == nil && v6 == nil {
+		return parseErr
+	}
+	// fail reports a failure of the family that was read successfully
+	// alongside the other family's parse error, each under its own label.
+	fail := func(applyErr *IPSettingError) *IPSettingError {
+		if v6Err != nil {
+			return combineIPSettingErrors(applyErr, v6Err)
+		}
+		return combineIPSettingErrors(v4Err, applyErr)
+	}
+
+	var routes []Route
+	if raw, err := f.Client.Read(vifKey + routesSubKey); err == nil {
+		parsed, err := parseRoutes(raw)
+		if err != nil {
+			f.logger.Printf("FeatureIPSetting parse routes failed with %s\n", err.Error())
+			return fail(newIPSettingError(ErrParseFailure, "parse routes: %s", err.Error()))
+		}
+		routes = parsed
+	}
+
+	iface, err := resolveInterfaceByMAC(mac)
+	if err != nil {
+		f.logger.Printf("FeatureIPSetting resolve interface for MAC %s failed with %s\n", mac, err.Error())
+		return fail(newIPSettingError(ErrInterfaceNotFound, "%s", err.Error()))
+	}
+
+	backend := SelectNetworkBackend(osType)
+	if err := backend.Apply(iface, v4, v6, dns, routes); err != nil {
+		f.logger.Printf("FeatureIPSetting Apply on %s failed with %s\n", iface, err.Error())
+		return fail(newIPSettingError(ErrBackendExecFailure, "%s", err.Error()))
+	}
+
+	if err := verifyApplied(iface, v4, v6); err != nil {
+		f.logger.Printf("FeatureIPSetting verify on %s failed with %s, rolling back\n", iface, err.Error())
+		if rbErr := backend.Rollback(); rbErr != nil {
+			f.logger.Printf("FeatureIPSetting rollback on %s failed with %s\n", iface, rbErr.Error())
+			return fail(newIPSettingError(ErrVerificationFailure, "verify failed (%s), rollback failed (%s)", err.Error(), rbErr.Error()))
+		}
+		return fail(newIPSettingError(ErrRollbackPerformed, "verify failed (%s), previous configuration restored", err.Error()))
+	}
+
+	f.logger.Printf("FeatureIPSetting applied static IP on %s\n", iface)
+	return parseErr
+}
+
+// reportIPSettingResult writes the outcome of a ConfigStaticIP attempt
+// back to the error-code/error-msg xenstore sub-keys under subkey so
+// the toolstack in dom0 can surface it to the user.
+func (f *FeatureIPSetting) reportIPSettingResult(subkey string, ipErr *IPSettingError) {
+	code := ErrNone
+	message := "OK"
+	if ipErr != nil {
+		code = ipErr.Code
+		message = ipErr.Message
+	}
+	if err := f.Client.Write(subkey+errorCodeSubKey, fmt.Sprintf("%d", code)); err != nil {
+		f.logger.Printf("FeatureIPSetting write error-code for %s failed with %s\n", subkey, err.Error())
+	}
+	if err := f.Client.Write(subkey+errorMsgSubKey, message); err != nil {
+		f.logger.Printf("FeatureIPSetting write error-msg for %s failed with %s\n", subkey, err.Error())
+	}
+}
+
+// debounceWindow bounds how often a burst of VIF watch events triggers
+// a re-scan of the control/vif tree.
+const debounceWindow = 500 * time.Millisecond
+
+// processChildren re-enumerates the VIF control tree and runs
+// ConfigStaticIP for every VIF that has static IP (v4 and/or v6)
+// enabled, in one call per VIF so a dual-stack VIF gets both families
+// applied together instead of as two overwriting passes.
+func (f *FeatureIPSetting) processChildren(osType OSType) {
+	for _, subkey := range f.GetChildrens(controlKey) {
+		f.logger.Printf("Start checking key %s", subkey)
+		macKey := subkey + macSubKey
+		mac, err := f.Client.Read(macKey)
+		if err != nil {
+			f.logger.Printf("FeatureIPSetting get mac for %#v failed with %#v\n", macKey, err)
+			continue
+		}
+
+		enableV4 := false
+		if ipenabled, err := f.Client.Read(subkey + ipenabledSubKey); err == nil {
+			enableV4 = ipenabled == "1"
+		}
+		enableV6 := false
+		if ipv6enabled, err := f.Client.Read(subkey + ipv6enabledSubKey); err == nil {
+			enableV6 = ipv6enabled == "1"
+		}
+		if !enableV4 && !enableV6 {
+			continue
+		}
+
+		f.reportIPSettingResult(subkey, f.ConfigStaticIP(subkey, mac, enableV4, enableV6, osType))
+	}
+}
+
+// watchEvents relays WatchEvent notifications onto a channel so Run can
+// select on them alongside ctx.Done(), since WatchEvent itself has no
+// notion of cancellation.
+//
+// KNOWN LIMITATION: f.Client.WatchEvent blocks until dom0 writes to
+// controlKey, and xenstoreclient.XenStoreClient (vendored separately,
+// not part of this checkout) exposes no way to interrupt a blocked
+// WatchEvent call or to unwatch controlKey from another goroutine. So
+// when Stop cancels f.ctx, this goroutine can remain parked inside
+// WatchEvent until the next xenstore write on controlKey wakes it,
+// rather than exiting immediately. If xenstoreclient grows an
+// Unwatch/Close that can interrupt WatchEvent, Stop should call it here
+// instead of relying solely on ctx cancellation.
+func (f *FeatureIPSetting) watchEvents(events chan<- struct{}) {
+	defer close(events)
+	for {
+		if _, ok := f.Client.WatchEvent(controlKey); !ok {
+			return
+		}
+		select {
+		case events <- struct{}{}:
+		case <-f.ctx.Done():
+			return
+		}
+	}
 }
 
 func (f *FeatureIPSetting) Run() error {
@@ -165,44 +449,47 @@ func (f *FeatureIPSetting) Run() error {
 	}
 
 	f.logger.Printf("Start watch on %#v\n", controlKey)
+	events := make(chan struct{})
+	go f.watchEvents(events)
+
 	go func() {
 		osType := GetCurrentOSType()
-		ticker := time.Tick(4 * time.Second)
-		for {
-			f.Enable()
-			if _, ok := f.Client.WatchEvent(controlKey); ok {
-				childrens := f.GetChildrens(controlKey)
-				for _, subkey := range childrens {
-					f.logger.Printf("Start checking key %s", subkey)
-					macKey := subkey + macSubKey
-					mac, err := f.Client.Read(macKey)
-					if err != nil {
-						f.logger.Printf("FeatureIPSetting get mac for %#v failed with %#v\n", macKey, err)
-						continue
-					}
-
-					ipenabledKey := subkey + ipenabledSubKey
-					if ipenabled, err := f.Client.Read(ipenabledKey); err == nil {
-						if ipenabled == "1" {
-							f.ConfigStaticIP(subkey, mac, false, osType)
-						}
-					}
-
-					ipv6enabledKey := subkey + ipv6enabledSubKey
-					if ipv6enabled, err := f.Client.Read(ipv6enabledKey); err == nil {
-						if ipv6enabled == "1" {
-							f.ConfigStaticIP(subkey, mac, true, osType)
-						}
-					}
+		f.Enable()
+		f.processChildren(osType)
 
-				}
-			}
+		debounce := time.NewTimer(debounceWindow)
+		if !debounce.Stop() {
+			<-debounce.C
+		}
+		pending := false
+		for {
 			select {
-			case <-ticker:
-				continue
+			case <-f.ctx.Done():
+				f.logger.Printf("FeatureIPSetting Run stopping\n")
+				return
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				pending = true
+				debounce.Reset(debounceWindow)
+			case <-debounce.C:
+				if !pending {
+					continue
+				}
+				pending = false
+				f.Enable()
+				f.processChildren(osType)
 			}
-
 		}
 	}()
 	return nil
 }
+
+// Stop cancels the context driving Run's watch loop and lets its
+// processChildren goroutine exit promptly. The watchEvents relay
+// goroutine may still take until the next xenstore write on controlKey
+// to exit; see the KNOWN LIMITATION note on watchEvents.
+func (f *FeatureIPSetting) Stop() {
+	f.cancel()
+}
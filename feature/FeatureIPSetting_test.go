@@ -0,0 +1,137 @@
+package feature
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseDNSList(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"single", "8.8.8.8", []string{"8.8.8.8"}},
+		{"multiple", "8.8.8.8,8.8.4.4", []string{"8.8.8.8", "8.8.4.4"}},
+		{"whitespace and blanks", " 8.8.8.8 ,, 2001:4860:4860::8888 ", []string{"8.8.8.8", "2001:4860:4860::8888"}},
+		{"invalid entries dropped", "8.8.8.8,not-an-ip", []string{"8.8.8.8"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := parseDNSList(c.raw)
+			if len(got) != len(c.want) {
+				t.Fatalf("parseDNSList(%q) = %v, want %v", c.raw, got, c.want)
+			}
+			for i, ip := range got {
+				if ip.String() != c.want[i] {
+					t.Errorf("parseDNSList(%q)[%d] = %s, want %s", c.raw, i, ip.String(), c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		want    []Route
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{
+			"single v4 entry",
+			"10.0.1.0/24,10.0.0.1",
+			[]Route{{Destination: mustParseCIDR(t, "10.0.1.0/24"), Via: net.ParseIP("10.0.0.1")}},
+			false,
+		},
+		{
+			"semicolon-separated v4 and v6 entries",
+			"10.0.1.0/24,10.0.0.1;2001:db8:1::/64,2001:db8::1",
+			[]Route{
+				{Destination: mustParseCIDR(t, "10.0.1.0/24"), Via: net.ParseIP("10.0.0.1")},
+				{Destination: mustParseCIDR(t, "2001:db8:1::/64"), Via: net.ParseIP("2001:db8::1")},
+			},
+			false,
+		},
+		{"missing via field", "10.0.1.0/24", nil, true},
+		{"bad cidr", "not-a-cidr,10.0.0.1", nil, true},
+		{"bad via", "10.0.1.0/24,not-an-ip", nil, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseRoutes(c.raw)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseRoutes(%q) = %v, want error", c.raw, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRoutes(%q) unexpected error: %s", c.raw, err.Error())
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("parseRoutes(%q) = %#v, want %#v", c.raw, got, c.want)
+			}
+			for i := range got {
+				if got[i].Destination.String() != c.want[i].Destination.String() || !got[i].Via.Equal(c.want[i].Via) {
+					t.Errorf("parseRoutes(%q)[%d] = %#v, want %#v", c.raw, i, got[i], c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	ip, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("ParseCIDR(%q): %s", s, err.Error())
+	}
+	ipNet.IP = ip
+	return ipNet
+}
+
+func TestClassifyOSRelease(t *testing.T) {
+	cases := []struct {
+		name   string
+		values map[string]string
+		want   OSType
+	}{
+		{"centos", map[string]string{"ID": "centos"}, CENTOS},
+		{"fedora", map[string]string{"ID": "fedora"}, FEDORA},
+		{"rhel", map[string]string{"ID": "rhel"}, RHEL},
+		{"ubuntu", map[string]string{"ID": "ubuntu"}, UBUNTU},
+		{"debian", map[string]string{"ID": "debian"}, DEBIAN},
+		{"suse via ID_LIKE", map[string]string{"ID": "sles", "ID_LIKE": "suse"}, SUSE},
+		{"unknown", map[string]string{"ID": "solaris"}, OTHER},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := classifyOSRelease(c.values)
+			wantOK := c.want != OTHER
+			if ok != wantOK {
+				t.Fatalf("classifyOSRelease(%v) ok = %v, want %v", c.values, ok, wantOK)
+			}
+			if got != c.want {
+				t.Errorf("classifyOSRelease(%v) = %v, want %v", c.values, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyValueFile(t *testing.T) {
+	r := strings.NewReader("ID=\"centos\"\nID_LIKE = rhel fedora\n# comment\nNO_EQUALS_SIGN\nVERSION=7\n")
+	got := parseKeyValueFile(r)
+	want := map[string]string{"ID": "centos", "ID_LIKE": "rhel fedora", "VERSION": "7"}
+	if len(got) != len(want) {
+		t.Fatalf("parseKeyValueFile = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("parseKeyValueFile[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package feature
+
+import "fmt"
+
+// IPSettingErrorCode is written to the error-code xenstore sub-key
+// after every ConfigStaticIP attempt so dom0 can tell the toolstack,
+// and ultimately the user, what happened.
+type IPSettingErrorCode int
+
+const (
+	// ErrNone means the static IP configuration was applied and its
+	// address was found on the interface afterwards. The gateway probe
+	// only logs a warning and does not change the code.
+	ErrNone IPSettingErrorCode = iota
+	// ErrParseFailure means the address/gateway/DNS/route values read
+	// from xenstore could not be parsed.
+	ErrParseFailure
+	// ErrInterfaceNotFound means no kernel interface could be matched
+	// to the VIF's MAC address.
+	ErrInterfaceNotFound
+	// ErrBackendExecFailure means the distro-specific backend failed to
+	// write its configuration or run the command that applies it.
+	ErrBackendExecFailure
+	// ErrVerificationFailure means configuration was applied but its
+	// address was not found on the interface afterwards, and restoring
+	// the previous configuration failed too. An unreachable gateway
+	// alone does not produce this code.
+	ErrVerificationFailure
+	// ErrRollbackPerformed means verification failed and the previous
+	// configuration was successfully restored.
+	ErrRollbackPerformed
+)
+
+// IPSettingError is the error type ConfigStaticIP returns, pairing a
+// documented IPSettingErrorCode with a human-readable message so Run can
+// report both to xenstore. NetworkBackend implementations return plain
+// errors, which ConfigStaticIP wraps with ErrBackendExecFailure.
+type IPSettingError struct {
+	Code    IPSettingErrorCode
+	Message string
+}
+
+func (e *IPSettingError) Error() string {
+	return e.Message
+}
+
+func newIPSettingError(code IPSettingErrorCode, format string, args ...interface{}) *IPSettingError {
+	return &IPSettingError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errorSeverity ranks each IPSettingErrorCode from least to most severe,
+// worst outcome last. It is a deliberate ordering rather than the raw
+// iota value: ErrRollbackPerformed means the family recovered back to
+// its previous working configuration, so it is less severe than
+// ErrVerificationFailure, which means the rollback itself failed and
+// the guest may be left unreachable on that family.
+var errorSeverity = map[IPSettingErrorCode]int{
+	ErrNone:                0,
+	ErrParseFailure:        1,
+	ErrRollbackPerformed:   2,
+	ErrInterfaceNotFound:   3,
+	ErrBackendExecFailure:  4,
+	ErrVerificationFailure: 5,
+}
+
+// combineIPSettingErrors merges the v4 and v6 outcomes of a dual-stack
+// ConfigStaticIP call into the single error reported back to xenstore,
+// so that a failure in one family can never be masked by success in the
+// other: either nil input is dropped, and if both are non-nil the more
+// severe IPSettingErrorCode (per errorSeverity, not raw code order)
+// wins while both messages are kept.
+func combineIPSettingErrors(v4Err *IPSettingError, v6Err *IPSettingError) *IPSettingError {
+	switch {
+	case v4Err == nil:
+		return v6Err
+	case v6Err == nil:
+		return v4Err
+	}
+	code := v4Err.Code
+	if errorSeverity[v6Err.Code] > errorSeverity[code] {
+		code = v6Err.Code
+	}
+	return &IPSettingError{
+		Code:    code,
+		Message: fmt.Sprintf("ipv4: %s; ipv6: %s", v4Err.Message, v6Err.Message),
+	}
+}
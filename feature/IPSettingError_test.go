@@ -0,0 +1,59 @@
+package feature
+
+import "testing"
+
+func TestCombineIPSettingErrors(t *testing.T) {
+	parseErr := newIPSettingError(ErrParseFailure, "v4 bad")
+	rollbackErr := newIPSettingError(ErrRollbackPerformed, "v6 rolled back")
+	verifyErr := newIPSettingError(ErrVerificationFailure, "v6 rollback itself failed")
+
+	cases := []struct {
+		name     string
+		v4, v6   *IPSettingError
+		wantCode IPSettingErrorCode
+		wantNil  bool
+	}{
+		{"both nil", nil, nil, ErrNone, true},
+		{"only v4", parseErr, nil, ErrParseFailure, false},
+		{"only v6", nil, rollbackErr, ErrRollbackPerformed, false},
+		{"both set keeps more severe code", parseErr, rollbackErr, ErrRollbackPerformed, false},
+		{
+			"recovered rollback does not mask an unreachable family",
+			rollbackErr, verifyErr,
+			ErrVerificationFailure, false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := combineIPSettingErrors(c.v4, c.v6)
+			if c.wantNil {
+				if got != nil {
+					t.Fatalf("combineIPSettingErrors(%v, %v) = %v, want nil", c.v4, c.v6, got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("combineIPSettingErrors(%v, %v) = nil, want code %v", c.v4, c.v6, c.wantCode)
+			}
+			if got.Code != c.wantCode {
+				t.Errorf("combineIPSettingErrors(%v, %v).Code = %v, want %v", c.v4, c.v6, got.Code, c.wantCode)
+			}
+		})
+	}
+
+	combined := combineIPSettingErrors(parseErr, rollbackErr)
+	if combined.Message == "" {
+		t.Fatalf("combineIPSettingErrors(%v, %v) lost both messages", parseErr, rollbackErr)
+	}
+}
+
+func TestIPSettingErrorError(t *testing.T) {
+	err := newIPSettingError(ErrInterfaceNotFound, "no interface for %s", "aa:bb:cc:dd:ee:ff")
+	want := "no interface for aa:bb:cc:dd:ee:ff"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if err.Code != ErrInterfaceNotFound {
+		t.Errorf("Code = %v, want %v", err.Code, ErrInterfaceNotFound)
+	}
+}
@@ -0,0 +1,80 @@
+package feature
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// IPConfig describes the address and gateway a NetworkBackend should
+// apply to an interface for a single address family.
+type IPConfig struct {
+	Address *net.IPNet
+	Gateway net.IP
+}
+
+// Route is a single static route to be applied alongside an IPConfig.
+// Via may be nil for an on-link route.
+type Route struct {
+	Destination *net.IPNet
+	Via         net.IP
+}
+
+// NetworkBackend applies static network configuration using whatever
+// mechanism is native to the guest's distro (ifcfg files, netplan,
+// systemd-networkd or NetworkManager). Apply is expected to persist the
+// configuration so it survives a reboot, not just take effect live.
+//
+// Rollback restores whatever NetworkBackend observed on disk/in the
+// running configuration the last time Apply was called, so a failed
+// verification can undo a bad push from xenstore.
+type NetworkBackend interface {
+	Apply(iface string, v4 *IPConfig, v6 *IPConfig, dns []net.IP, routes []Route) error
+	Rollback() error
+}
+
+// SelectNetworkBackend returns the NetworkBackend appropriate for osType.
+func SelectNetworkBackend(osType OSType) NetworkBackend {
+	switch osType {
+	case CENTOS, RHEL, FEDORA:
+		return NewRHELBackend()
+	case DEBIAN, UBUNTU:
+		return NewNetplanBackend()
+	case SUSE:
+		return NewSystemdNetworkdBackend()
+	default:
+		return NewNetworkManagerBackend()
+	}
+}
+
+// resolveInterfaceByMAC maps a MAC address read from xenstore to the
+// kernel interface name currently bound to it by scanning
+// /sys/class/net/*/address, since xenstore only ever knows VIFs by MAC.
+func resolveInterfaceByMAC(mac string) (string, error) {
+	matches, err := filepath.Glob("/sys/class/net/*/address")
+	if err != nil {
+		return "", err
+	}
+	want, err := net.ParseMAC(mac)
+	if err != nil {
+		return "", fmt.Errorf("invalid MAC %q: %s", mac, err.Error())
+	}
+	for _, addressFile := range matches {
+		raw, err := ioutil.ReadFile(addressFile)
+		if err != nil {
+			continue
+		}
+		got, err := net.ParseMAC(strings.TrimSpace(string(raw)))
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(got, want) {
+			// addressFile looks like /sys/class/net/<iface>/address
+			return filepath.Base(filepath.Dir(addressFile)), nil
+		}
+	}
+	return "", fmt.Errorf("no interface found for MAC %q", mac)
+}
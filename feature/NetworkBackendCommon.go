@@ -0,0 +1,57 @@
+package feature
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// fileSnapshot remembers what a config file held (if anything) right
+// before a backend overwrote it, so Rollback can put it back exactly.
+type fileSnapshot struct {
+	path     string
+	existed  bool
+	contents []byte
+}
+
+// snapshotFile records the current contents of path, if any, before it
+// is about to be overwritten.
+func snapshotFile(path string) (fileSnapshot, error) {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fileSnapshot{path: path, existed: false}, nil
+	}
+	if err != nil {
+		return fileSnapshot{}, err
+	}
+	return fileSnapshot{path: path, existed: true, contents: contents}, nil
+}
+
+// restore puts the file back exactly as it was when snapshotFile ran,
+// removing it if it did not previously exist.
+func (s fileSnapshot) restore() error {
+	if s.path == "" {
+		return nil
+	}
+	if !s.existed {
+		err := os.Remove(s.path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return writeFileAtomicNoSnapshot(s.path, s.contents, 0644)
+}
+
+// writeFileAtomic writes content to path via a temp file + rename so a
+// crash mid-write can't leave a half-written config behind.
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	return writeFileAtomicNoSnapshot(path, content, perm)
+}
+
+func writeFileAtomicNoSnapshot(path string, content []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
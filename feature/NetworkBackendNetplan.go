@@ -0,0 +1,106 @@
+package feature
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+)
+
+// netplanConfigDir is where Debian/Ubuntu netplan keeps its YAML
+// renderer config.
+const netplanConfigDir = "/etc/netplan"
+
+// netplanFileName is the file we own; numbered high so it sorts after
+// the usual distro-shipped netplan files (0*-*.yaml). netplan reads the
+// files in lexical order, so for this interface the settings here are
+// applied on top of theirs.
+const netplanFileName = "90-xe-guest-utilities.yaml"
+
+// NetplanBackend applies static IP configuration by writing a netplan
+// YAML stanza and running `netplan apply`.
+type NetplanBackend struct {
+	snapshot fileSnapshot
+}
+
+// NewNetplanBackend returns a NetworkBackend for Debian/Ubuntu guests.
+func NewNetplanBackend() *NetplanBackend {
+	return &NetplanBackend{}
+}
+
+func (b *NetplanBackend) path() string {
+	return fmt.Sprintf("%s/%s", netplanConfigDir, netplanFileName)
+}
+
+func (b *NetplanBackend) Apply(iface string, v4 *IPConfig, v6 *IPConfig, dns []net.IP, routes []Route) error {
+	path := b.path()
+	snapshot, err := snapshotFile(path)
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %s", path, err.Error())
+	}
+	b.snapshot = snapshot
+
+	content := renderNetplan(iface, v4, v6, dns, routes)
+	if err := writeFileAtomic(path, content, 0600); err != nil {
+		return fmt.Errorf("write %s: %s", path, err.Error())
+	}
+
+	if out, err := exec.Command("netplan", "apply").CombinedOutput(); err != nil {
+		return fmt.Errorf("netplan apply: %s: %s", err.Error(), string(out))
+	}
+	return nil
+}
+
+func (b *NetplanBackend) Rollback() error {
+	if err := b.snapshot.restore(); err != nil {
+		return err
+	}
+	_, err := exec.Command("netplan", "apply").CombinedOutput()
+	return err
+}
+
+func renderNetplan(iface string, v4 *IPConfig, v6 *IPConfig, dns []net.IP, routes []Route) []byte {
+	out := "network:\n  version: 2\n  ethernets:\n"
+	out += fmt.Sprintf("    %s:\n", iface)
+	out += "      addresses:\n"
+	if v4 != nil && v4.Address != nil {
+		ones, _ := v4.Address.Mask.Size()
+		out += fmt.Sprintf("        - %s/%d\n", v4.Address.IP.String(), ones)
+	}
+	if v6 != nil && v6.Address != nil {
+		ones, _ := v6.Address.Mask.Size()
+		out += fmt.Sprintf("        - %s/%d\n", v6.Address.IP.String(), ones)
+	}
+
+	var gateway4, gateway6 string
+	if v4 != nil && v4.Gateway != nil {
+		gateway4 = v4.Gateway.String()
+	}
+	if v6 != nil && v6.Gateway != nil {
+		gateway6 = v6.Gateway.String()
+	}
+	if gateway4 != "" {
+		out += fmt.Sprintf("      gateway4: %s\n", gateway4)
+	}
+	if gateway6 != "" {
+		out += fmt.Sprintf("      gateway6: %s\n", gateway6)
+	}
+
+	if len(dns) > 0 {
+		out += "      nameservers:\n        addresses:\n"
+		for _, server := range dns {
+			out += fmt.Sprintf("          - %s\n", server.String())
+		}
+	}
+
+	if len(routes) > 0 {
+		out += "      routes:\n"
+		for _, route := range routes {
+			out += fmt.Sprintf("        - to: %s\n", route.Destination.String())
+			if route.Via != nil {
+				out += fmt.Sprintf("          via: %s\n", route.Via.String())
+			}
+		}
+	}
+
+	return []byte(out)
+}
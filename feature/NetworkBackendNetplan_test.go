@@ -0,0 +1,39 @@
+package feature
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRenderNetplan(t *testing.T) {
+	v4 := &IPConfig{Address: mustParseCIDR(t, "10.0.0.2/24"), Gateway: net.ParseIP("10.0.0.1")}
+	v6 := &IPConfig{Address: mustParseCIDR(t, "2001:db8::2/64"), Gateway: net.ParseIP("2001:db8::1")}
+	dns := []net.IP{net.ParseIP("8.8.8.8")}
+	routes := []Route{{Destination: mustParseCIDR(t, "10.1.0.0/16"), Via: net.ParseIP("10.0.0.1")}}
+
+	out := string(renderNetplan("eth0", v4, v6, dns, routes))
+
+	for _, want := range []string{
+		"eth0:",
+		"- 10.0.0.2/24",
+		"- 2001:db8::2/64",
+		"gateway4: 10.0.0.1",
+		"gateway6: 2001:db8::1",
+		"- 8.8.8.8",
+		"to: 10.1.0.0/16",
+		"via: 10.0.0.1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("renderNetplan output missing %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderNetplanV4Only(t *testing.T) {
+	v4 := &IPConfig{Address: mustParseCIDR(t, "10.0.0.2/24")}
+	out := string(renderNetplan("eth0", v4, nil, nil, nil))
+	if strings.Contains(out, "gateway6") || strings.Contains(out, "routes:") || strings.Contains(out, "nameservers:") {
+		t.Errorf("renderNetplan with no v6/dns/routes emitted unwanted stanza:\n%s", out)
+	}
+}
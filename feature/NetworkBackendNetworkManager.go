@@ -0,0 +1,128 @@
+package feature
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// nmcliProperties are the nmcli connection properties this backend
+// reads before Apply and restores on Rollback.
+var nmcliProperties = []string{"ipv4.method", "ipv4.addresses", "ipv4.gateway", "ipv4.dns", "ipv4.routes", "ipv6.method", "ipv6.addresses", "ipv6.gateway", "ipv6.dns", "ipv6.routes"}
+
+// NetworkManagerBackend applies static IP configuration by running
+// `nmcli con mod` against the connection whose name is the interface
+// name. A connection bound to the interface under another name is not
+// looked up; Apply then returns the error nmcli reports.
+type NetworkManagerBackend struct {
+	iface    string
+	snapshot map[string]string
+}
+
+// NewNetworkManagerBackend returns a NetworkBackend for guests managed
+// by NetworkManager.
+func NewNetworkManagerBackend() *NetworkManagerBackend {
+	return &NetworkManagerBackend{}
+}
+
+func (b *NetworkManagerBackend) Apply(iface string, v4 *IPConfig, v6 *IPConfig, dns []net.IP, routes []Route) error {
+	b.iface = iface
+	snapshot, err := nmcliSnapshot(iface)
+	if err != nil {
+		return fmt.Errorf("snapshot nmcli connection for %s: %s", iface, err.Error())
+	}
+	b.snapshot = snapshot
+
+	args := []string{"con", "mod", iface}
+	if v4 != nil && v4.Address != nil {
+		ones, _ := v4.Address.Mask.Size()
+		args = append(args, "ipv4.method", "manual", "ipv4.addresses", fmt.Sprintf("%s/%d", v4.Address.IP.String(), ones))
+		if v4.Gateway != nil {
+			args = append(args, "ipv4.gateway", v4.Gateway.String())
+		}
+	}
+	if v6 != nil && v6.Address != nil {
+		ones, _ := v6.Address.Mask.Size()
+		args = append(args, "ipv6.method", "manual", "ipv6.addresses", fmt.Sprintf("%s/%d", v6.Address.IP.String(), ones))
+		if v6.Gateway != nil {
+			args = append(args, "ipv6.gateway", v6.Gateway.String())
+		}
+	}
+	dns4, dns6 := splitDNSByFamily(dns)
+	if len(dns4) > 0 {
+		args = append(args, "ipv4.dns", joinIPs(dns4))
+	}
+	if len(dns6) > 0 {
+		args = append(args, "ipv6.dns", joinIPs(dns6))
+	}
+	for _, route := range routes {
+		via := ""
+		if route.Via != nil {
+			via = route.Via.String()
+		}
+		prop := "+ipv4.routes"
+		if route.Destination.IP.To4() == nil {
+			prop = "+ipv6.routes"
+		}
+		args = append(args, prop, fmt.Sprintf("%s %s", route.Destination.String(), via))
+	}
+
+	if out, err := exec.Command("nmcli", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("nmcli %s: %s: %s", strings.Join(args, " "), err.Error(), string(out))
+	}
+	if out, err := exec.Command("nmcli", "con", "up", iface).CombinedOutput(); err != nil {
+		return fmt.Errorf("nmcli con up %s: %s: %s", iface, err.Error(), string(out))
+	}
+	return nil
+}
+
+func (b *NetworkManagerBackend) Rollback() error {
+	if b.iface == "" {
+		return nil
+	}
+	args := []string{"con", "mod", b.iface}
+	for _, prop := range nmcliProperties {
+		args = append(args, prop, b.snapshot[prop])
+	}
+	if out, err := exec.Command("nmcli", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("nmcli %s: %s: %s", strings.Join(args, " "), err.Error(), string(out))
+	}
+	_, err := exec.Command("nmcli", "con", "up", b.iface).CombinedOutput()
+	return err
+}
+
+func nmcliSnapshot(iface string) (map[string]string, error) {
+	snapshot := make(map[string]string)
+	for _, prop := range nmcliProperties {
+		out, err := exec.Command("nmcli", "-g", prop, "con", "show", iface).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("nmcli -g %s con show %s: %s", prop, iface, err.Error())
+		}
+		snapshot[prop] = strings.TrimSpace(string(out))
+	}
+	return snapshot, nil
+}
+
+func joinIPs(ips []net.IP) string {
+	parts := make([]string, len(ips))
+	for i, ip := range ips {
+		parts[i] = ip.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// splitDNSByFamily splits a combined v4+v6 DNS server list (as produced
+// when a dual-stack VIF merges its dns and dns6 sub-keys) back out by
+// address family, since nmcli rejects an IPv6 server passed to
+// ipv4.dns and vice versa.
+func splitDNSByFamily(dns []net.IP) (dns4 []net.IP, dns6 []net.IP) {
+	for _, server := range dns {
+		if server.To4() != nil {
+			dns4 = append(dns4, server)
+		} else {
+			dns6 = append(dns6, server)
+		}
+	}
+	return dns4, dns6
+}
@@ -0,0 +1,26 @@
+package feature
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSplitDNSByFamily(t *testing.T) {
+	dns := []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("2001:4860:4860::8888"), net.ParseIP("8.8.4.4")}
+	dns4, dns6 := splitDNSByFamily(dns)
+
+	if len(dns4) != 2 || dns4[0].String() != "8.8.8.8" || dns4[1].String() != "8.8.4.4" {
+		t.Errorf("splitDNSByFamily dns4 = %v, want [8.8.8.8 8.8.4.4]", dns4)
+	}
+	if len(dns6) != 1 || dns6[0].String() != "2001:4860:4860::8888" {
+		t.Errorf("splitDNSByFamily dns6 = %v, want [2001:4860:4860::8888]", dns6)
+	}
+}
+
+func TestJoinIPs(t *testing.T) {
+	got := joinIPs([]net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")})
+	want := "8.8.8.8,8.8.4.4"
+	if got != want {
+		t.Errorf("joinIPs = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,176 @@
+package feature
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+)
+
+// rhelNetworkScriptsDir is where RHEL/CentOS keeps its legacy
+// ifcfg-<iface> network scripts.
+const rhelNetworkScriptsDir = "/etc/sysconfig/network-scripts"
+
+// RHELBackend applies static IP configuration by writing an
+// ifcfg-<iface> file (and, when routes are configured, route-<iface> /
+// route6-<iface> files) under /etc/sysconfig/network-scripts and
+// cycling the interface with ifdown/ifup.
+type RHELBackend struct {
+	snapshot       fileSnapshot
+	routeSnapshot  fileSnapshot
+	route6Snapshot fileSnapshot
+}
+
+// NewRHELBackend returns a NetworkBackend for RHEL/CentOS-family guests.
+func NewRHELBackend() *RHELBackend {
+	return &RHELBackend{}
+}
+
+func (b *RHELBackend) ifcfgPath(iface string) string {
+	return fmt.Sprintf("%s/ifcfg-%s", rhelNetworkScriptsDir, iface)
+}
+
+func (b *RHELBackend) routePath(iface string) string {
+	return fmt.Sprintf("%s/route-%s", rhelNetworkScriptsDir, iface)
+}
+
+func (b *RHELBackend) route6Path(iface string) string {
+	return fmt.Sprintf("%s/route6-%s", rhelNetworkScriptsDir, iface)
+}
+
+func (b *RHELBackend) Apply(iface string, v4 *IPConfig, v6 *IPConfig, dns []net.IP, routes []Route) error {
+	path := b.ifcfgPath(iface)
+	snapshot, err := snapshotFile(path)
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %s", path, err.Error())
+	}
+	b.snapshot = snapshot
+
+	routePath := b.routePath(iface)
+	routeSnapshot, err := snapshotFile(routePath)
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %s", routePath, err.Error())
+	}
+	b.routeSnapshot = routeSnapshot
+
+	route6Path := b.route6Path(iface)
+	route6Snapshot, err := snapshotFile(route6Path)
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %s", route6Path, err.Error())
+	}
+	b.route6Snapshot = route6Snapshot
+
+	content := renderIfcfg(iface, v4, v6, dns, routes)
+	if err := writeFileAtomic(path, content, 0644); err != nil {
+		return fmt.Errorf("write %s: %s", path, err.Error())
+	}
+
+	routes4, routes6 := splitRoutesByFamily(routes)
+	if err := writeFileAtomic(routePath, renderRouteFile(routes4), 0644); err != nil {
+		return fmt.Errorf("write %s: %s", routePath, err.Error())
+	}
+	if err := writeFileAtomic(route6Path, renderRouteFile(routes6), 0644); err != nil {
+		return fmt.Errorf("write %s: %s", route6Path, err.Error())
+	}
+
+	if out, err := exec.Command("ifdown", iface).CombinedOutput(); err != nil {
+		return fmt.Errorf("ifdown %s: %s: %s", iface, err.Error(), string(out))
+	}
+	if out, err := exec.Command("ifup", iface).CombinedOutput(); err != nil {
+		return fmt.Errorf("ifup %s: %s: %s", iface, err.Error(), string(out))
+	}
+	return nil
+}
+
+func (b *RHELBackend) Rollback() error {
+	if err := b.route6Snapshot.restore(); err != nil {
+		return err
+	}
+	if err := b.routeSnapshot.restore(); err != nil {
+		return err
+	}
+	if err := b.snapshot.restore(); err != nil {
+		return err
+	}
+	iface := ifaceFromIfcfgPath(b.snapshot.path)
+	if iface == "" {
+		return nil
+	}
+	exec.Command("ifdown", iface).Run()
+	_, err := exec.Command("ifup", iface).CombinedOutput()
+	return err
+}
+
+// splitRoutesByFamily splits routes into IPv4 and IPv6 destinations, so
+// each can be written to its own route-<iface>/route6-<iface> file.
+func splitRoutesByFamily(routes []Route) (routes4 []Route, routes6 []Route) {
+	for _, route := range routes {
+		if route.Destination.IP.To4() != nil {
+			routes4 = append(routes4, route)
+		} else {
+			routes6 = append(routes6, route)
+		}
+	}
+	return routes4, routes6
+}
+
+func ifaceFromIfcfgPath(path string) string {
+	const prefix = rhelNetworkScriptsDir + "/ifcfg-"
+	if len(path) <= len(prefix) || path[:len(prefix)] != prefix {
+		return ""
+	}
+	return path[len(prefix):]
+}
+
+func renderIfcfg(iface string, v4 *IPConfig, v6 *IPConfig, dns []net.IP, routes []Route) []byte {
+	lines := []string{
+		fmt.Sprintf("DEVICE=%s", iface),
+		"BOOTPROTO=none",
+		"ONBOOT=yes",
+	}
+	if v4 != nil && v4.Address != nil {
+		ones, _ := v4.Address.Mask.Size()
+		lines = append(lines,
+			fmt.Sprintf("IPADDR=%s", v4.Address.IP.String()),
+			fmt.Sprintf("PREFIX=%d", ones),
+		)
+		if v4.Gateway != nil {
+			lines = append(lines, fmt.Sprintf("GATEWAY=%s", v4.Gateway.String()))
+		}
+	}
+	if v6 != nil && v6.Address != nil {
+		ones, _ := v6.Address.Mask.Size()
+		lines = append(lines,
+			"IPV6INIT=yes",
+			fmt.Sprintf("IPV6ADDR=%s/%d", v6.Address.IP.String(), ones),
+		)
+		if v6.Gateway != nil {
+			lines = append(lines, fmt.Sprintf("IPV6_DEFAULTGW=%s", v6.Gateway.String()))
+		}
+	}
+	for i, server := range dns {
+		lines = append(lines, fmt.Sprintf("DNS%d=%s", i+1, server.String()))
+	}
+
+	out := ""
+	for _, line := range lines {
+		out += line + "\n"
+	}
+	return []byte(out)
+}
+
+// renderRouteFile renders routes in the iproute2-style syntax accepted
+// by the legacy network-scripts route-<iface> file: one
+// "<destination> via <gateway>" directive per line. Rendering an empty
+// routes slice yields an empty file, clearing any routes a previous
+// Apply left behind.
+func renderRouteFile(routes []Route) []byte {
+	out := ""
+	for _, route := range routes {
+		if route.Via != nil {
+			out += fmt.Sprintf("%s via %s\n", route.Destination.String(), route.Via.String())
+		} else {
+			out += fmt.Sprintf("%s\n", route.Destination.String())
+		}
+	}
+	return []byte(out)
+}
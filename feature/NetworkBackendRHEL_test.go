@@ -0,0 +1,77 @@
+package feature
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRenderIfcfg(t *testing.T) {
+	v4 := &IPConfig{Address: mustParseCIDR(t, "10.0.0.2/24"), Gateway: net.ParseIP("10.0.0.1")}
+	v6 := &IPConfig{Address: mustParseCIDR(t, "2001:db8::2/64"), Gateway: net.ParseIP("2001:db8::1")}
+	dns := []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")}
+
+	out := string(renderIfcfg("eth0", v4, v6, dns, nil))
+
+	for _, want := range []string{
+		"DEVICE=eth0",
+		"IPADDR=10.0.0.2",
+		"PREFIX=24",
+		"GATEWAY=10.0.0.1",
+		"IPV6ADDR=2001:db8::2/64",
+		"IPV6_DEFAULTGW=2001:db8::1",
+		"DNS1=8.8.8.8",
+		"DNS2=8.8.4.4",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("renderIfcfg output missing %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "ROUTE") {
+		t.Errorf("renderIfcfg should no longer emit route comments, got:\n%s", out)
+	}
+}
+
+func TestRenderRouteFile(t *testing.T) {
+	cases := []struct {
+		name   string
+		routes []Route
+		want   string
+	}{
+		{"empty", nil, ""},
+		{
+			"with via",
+			[]Route{{Destination: mustParseCIDR(t, "10.1.0.0/16"), Via: net.ParseIP("10.0.0.1")}},
+			"10.1.0.0/16 via 10.0.0.1\n",
+		},
+		{
+			"on-link, no via",
+			[]Route{{Destination: mustParseCIDR(t, "10.1.0.0/16")}},
+			"10.1.0.0/16\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := string(renderRouteFile(c.routes))
+			if got != c.want {
+				t.Errorf("renderRouteFile(%#v) = %q, want %q", c.routes, got, c.want)
+			}
+		})
+	}
+}
+
+func TestIfaceFromIfcfgPath(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{rhelNetworkScriptsDir + "/ifcfg-eth0", "eth0"},
+		{"/other/path", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := ifaceFromIfcfgPath(c.path); got != c.want {
+			t.Errorf("ifaceFromIfcfgPath(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
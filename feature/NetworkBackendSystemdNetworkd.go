@@ -0,0 +1,95 @@
+package feature
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+)
+
+// systemdNetworkdConfigDir is where systemd-networkd reads .network
+// drop-ins from.
+const systemdNetworkdConfigDir = "/etc/systemd/network"
+
+// SystemdNetworkdBackend applies static IP configuration by writing a
+// .network drop-in and reloading via `networkctl reload`.
+type SystemdNetworkdBackend struct {
+	snapshot fileSnapshot
+}
+
+// NewSystemdNetworkdBackend returns a NetworkBackend for guests managed
+// by systemd-networkd (e.g. SUSE).
+func NewSystemdNetworkdBackend() *SystemdNetworkdBackend {
+	return &SystemdNetworkdBackend{}
+}
+
+func (b *SystemdNetworkdBackend) path(iface string) string {
+	return fmt.Sprintf("%s/90-xe-guest-utilities-%s.network", systemdNetworkdConfigDir, iface)
+}
+
+func (b *SystemdNetworkdBackend) Apply(iface string, v4 *IPConfig, v6 *IPConfig, dns []net.IP, routes []Route) error {
+	path := b.path(iface)
+	snapshot, err := snapshotFile(path)
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %s", path, err.Error())
+	}
+	b.snapshot = snapshot
+
+	content := renderNetworkdUnit(iface, v4, v6, dns, routes)
+	if err := writeFileAtomic(path, content, 0644); err != nil {
+		return fmt.Errorf("write %s: %s", path, err.Error())
+	}
+
+	if out, err := exec.Command("networkctl", "reload").CombinedOutput(); err != nil {
+		return fmt.Errorf("networkctl reload: %s: %s", err.Error(), string(out))
+	}
+	return nil
+}
+
+func (b *SystemdNetworkdBackend) Rollback() error {
+	if err := b.snapshot.restore(); err != nil {
+		return err
+	}
+	_, err := exec.Command("networkctl", "reload").CombinedOutput()
+	return err
+}
+
+func renderNetworkdUnit(iface string, v4 *IPConfig, v6 *IPConfig, dns []net.IP, routes []Route) []byte {
+	out := "[Match]\n"
+	out += fmt.Sprintf("Name=%s\n\n[Network]\n", iface)
+
+	if v4 != nil && v4.Address != nil {
+		ones, _ := v4.Address.Mask.Size()
+		out += fmt.Sprintf("Address=%s/%d\n", v4.Address.IP.String(), ones)
+	}
+	if v6 != nil && v6.Address != nil {
+		ones, _ := v6.Address.Mask.Size()
+		out += fmt.Sprintf("Address=%s/%d\n", v6.Address.IP.String(), ones)
+	}
+	for _, server := range dns {
+		out += fmt.Sprintf("DNS=%s\n", server.String())
+	}
+
+	var gateway4, gateway6 string
+	if v4 != nil && v4.Gateway != nil {
+		gateway4 = v4.Gateway.String()
+	}
+	if v6 != nil && v6.Gateway != nil {
+		gateway6 = v6.Gateway.String()
+	}
+	if gateway4 != "" {
+		out += fmt.Sprintf("Gateway=%s\n", gateway4)
+	}
+	if gateway6 != "" {
+		out += fmt.Sprintf("Gateway=%s\n", gateway6)
+	}
+
+	for _, route := range routes {
+		out += "\n[Route]\n"
+		out += fmt.Sprintf("Destination=%s\n", route.Destination.String())
+		if route.Via != nil {
+			out += fmt.Sprintf("Gateway=%s\n", route.Via.String())
+		}
+	}
+
+	return []byte(out)
+}
@@ -0,0 +1,36 @@
+package feature
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRenderNetworkdUnit(t *testing.T) {
+	v4 := &IPConfig{Address: mustParseCIDR(t, "10.0.0.2/24"), Gateway: net.ParseIP("10.0.0.1")}
+	dns := []net.IP{net.ParseIP("8.8.8.8")}
+	routes := []Route{{Destination: mustParseCIDR(t, "10.1.0.0/16"), Via: net.ParseIP("10.0.0.1")}}
+
+	out := string(renderNetworkdUnit("eth0", v4, nil, dns, routes))
+
+	for _, want := range []string{
+		"Name=eth0",
+		"Address=10.0.0.2/24",
+		"Gateway=10.0.0.1",
+		"DNS=8.8.8.8",
+		"[Route]",
+		"Destination=10.1.0.0/16",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("renderNetworkdUnit output missing %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderNetworkdUnitNoRoutes(t *testing.T) {
+	v4 := &IPConfig{Address: mustParseCIDR(t, "10.0.0.2/24")}
+	out := string(renderNetworkdUnit("eth0", v4, nil, nil, nil))
+	if strings.Contains(out, "[Route]") {
+		t.Errorf("renderNetworkdUnit with no routes emitted a [Route] stanza:\n%s", out)
+	}
+}
@@ -0,0 +1,121 @@
+package feature
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os/exec"
+	"time"
+)
+
+// verifyProbeTimeout bounds how long the ARP/ICMP reachability probe is
+// allowed to take, so a dead gateway is logged quickly rather than
+// hanging the watch loop.
+const verifyProbeTimeout = 2 * time.Second
+
+// verifyApplied confirms that v4/v6 actually took effect on iface: the
+// address must show up in the kernel's view of the interface. That is
+// what decides whether ConfigStaticIP needs to roll back. The
+// configured gateway (if any) is also probed for reachability, but only
+// as a best-effort diagnostic logged via probeGateway: plenty of valid
+// gateways drop unsolicited ARP/ICMP probes, so a failed probe alone
+// must not turn a correctly-applied address into a rollback.
+func verifyApplied(iface string, v4 *IPConfig, v6 *IPConfig) error {
+	if err := verifyAddressPresent(iface, v4); err != nil {
+		return err
+	}
+	if err := verifyAddressPresent(iface, v6); err != nil {
+		return err
+	}
+	probeGateway(iface, v4)
+	probeGateway(iface, v6)
+	return nil
+}
+
+// ipAddrShow is the subset of `ip -j addr show` output this package
+// cares about: the addr_info entries for a single link.
+type ipAddrShow struct {
+	AddrInfo []struct {
+		Local     string `json:"local"`
+		Prefixlen int    `json:"prefixlen"`
+	} `json:"addr_info"`
+}
+
+// verifyAddressPresent confirms cfg's address shows up on iface. It
+// only returns an error - triggering a rollback - when `ip -j addr
+// show` ran and its output was parsed cleanly but cfg's address simply
+// isn't there. If the command itself fails (binary missing) or its
+// output can't be parsed (pre-7.6 iproute2, the tool's historical
+// primary target, predates `-j`/JSON support), presence can't be
+// determined either way; that is logged and treated as a pass rather
+// than rolling back a config that may well have applied correctly.
+func verifyAddressPresent(iface string, cfg *IPConfig) error {
+	if cfg == nil || cfg.Address == nil {
+		return nil
+	}
+	out, err := exec.Command("ip", "-j", "addr", "show", "dev", iface).CombinedOutput()
+	if err != nil {
+		log.Printf("verifyAddressPresent: ip -j addr show %s failed (%s: %s), cannot confirm %s -- not rolling back\n", iface, err.Error(), string(out), cfg.Address.String())
+		return nil
+	}
+	present, err := addressInIPAddrShowJSON(out, cfg.Address)
+	if err != nil {
+		log.Printf("verifyAddressPresent: could not parse ip -j addr show %s output (%s), cannot confirm %s -- not rolling back\n", iface, err.Error(), cfg.Address.String())
+		return nil
+	}
+	if !present {
+		return fmt.Errorf("address %s not present on %s after apply", cfg.Address.String(), iface)
+	}
+	return nil
+}
+
+// addressInIPAddrShowJSON reports whether want (both IP and prefix
+// length) appears among the addresses in out, the JSON produced by
+// `ip -j addr show`. A plain substring search would count 10.0.0.10 as
+// a match for 10.0.0.1, so this matches full address/prefixlen pairs
+// instead.
+func addressInIPAddrShowJSON(out []byte, want *net.IPNet) (bool, error) {
+	var links []ipAddrShow
+	if err := json.Unmarshal(out, &links); err != nil {
+		return false, err
+	}
+	ones, _ := want.Mask.Size()
+	for _, link := range links {
+		for _, addr := range link.AddrInfo {
+			if addr.Local == want.IP.String() && addr.Prefixlen == ones {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// probeGateway best-effort-probes the configured gateway with a single
+// ARP request (IPv4, via arping) or ICMPv6 echo request (IPv6, via
+// ping -6 - this is not a neighbor solicitation). It only logs a
+// warning on failure rather than returning an error: the probe binary
+// may not be installed, and plenty of valid gateways simply don't
+// answer ARP/ICMP from a host they haven't talked to yet, so failing
+// verification (and rolling back an otherwise-correct address) on a
+// dropped probe would do more harm than good.
+func probeGateway(iface string, cfg *IPConfig) {
+	if cfg == nil || cfg.Gateway == nil {
+		return
+	}
+
+	timeout := fmt.Sprintf("%d", int(verifyProbeTimeout/time.Second))
+	name := "arping"
+	args := []string{"-c", "1", "-w", timeout, "-I", iface, cfg.Gateway.String()}
+	if cfg.Gateway.To4() == nil {
+		name = "ping"
+		args = []string{"-6", "-c", "1", "-W", timeout, "-I", iface, cfg.Gateway.String()}
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		log.Printf("probeGateway: %s not installed, skipping reachability probe for gateway %s on %s\n", name, cfg.Gateway.String(), iface)
+		return
+	}
+	if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		log.Printf("probeGateway: gateway %s via %s unreachable (treated as a warning, not a rollback): %s: %s\n", cfg.Gateway.String(), iface, err.Error(), string(out))
+	}
+}
@@ -0,0 +1,37 @@
+package feature
+
+import "testing"
+
+func TestAddressInIPAddrShowJSON(t *testing.T) {
+	out := []byte(`[{"ifname":"eth0","addr_info":[{"family":"inet","local":"10.0.0.10","prefixlen":24},{"family":"inet6","local":"fe80::1","prefixlen":64}]}]`)
+
+	present, err := addressInIPAddrShowJSON(out, mustParseCIDR(t, "10.0.0.1/24"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if present {
+		t.Errorf("10.0.0.1/24 reported present, but only 10.0.0.10/24 is on the interface (substring-match regression)")
+	}
+
+	present, err = addressInIPAddrShowJSON(out, mustParseCIDR(t, "10.0.0.10/24"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if !present {
+		t.Errorf("10.0.0.10/24 reported absent, but it is present in the JSON")
+	}
+
+	present, err = addressInIPAddrShowJSON(out, mustParseCIDR(t, "10.0.0.10/16"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if present {
+		t.Errorf("10.0.0.10/16 reported present, but the interface has a /24")
+	}
+}
+
+func TestAddressInIPAddrShowJSONMalformed(t *testing.T) {
+	if _, err := addressInIPAddrShowJSON([]byte("not json"), mustParseCIDR(t, "10.0.0.1/24")); err == nil {
+		t.Fatal("expected an error for malformed JSON, got nil")
+	}
+}
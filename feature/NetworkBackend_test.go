@@ -0,0 +1,17 @@
+package feature
+
+import "testing"
+
+func TestResolveInterfaceByMACInvalidMAC(t *testing.T) {
+	if _, err := resolveInterfaceByMAC("not-a-mac"); err == nil {
+		t.Fatal("expected an error for an invalid MAC, got nil")
+	}
+}
+
+func TestResolveInterfaceByMACNotFound(t *testing.T) {
+	// This MAC is reserved for documentation (RFC 7042) and should never
+	// be bound to a real interface.
+	if _, err := resolveInterfaceByMAC("00:00:5e:00:53:01"); err == nil {
+		t.Fatal("expected an error for an unbound MAC, got nil")
+	}
+}